@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerState is a server's current circuit breaker phase.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "CLOSED"    // Requests flow normally
+	BreakerOpen     BreakerState = "OPEN"      // Requests are rejected until the cooldown elapses
+	BreakerHalfOpen BreakerState = "HALF_OPEN" // A limited number of probe requests are admitted to test recovery
+)
+
+// breakerJitter mixes +/-20% randomness into every cooldown so that many
+// servers tripped at once don't all retry in lockstep.
+const breakerJitter = 0.2
+
+// maxBreakerBackoffShift bounds the exponential backoff's exponent so the
+// cooldown computation can't overflow before MaxCooldown caps it.
+const maxBreakerBackoffShift = 20
+
+// tripBreaker opens the circuit breaker and schedules its next half-open
+// probe via base*2^(BreakerTrips-1) backoff (so the first trip waits base),
+// capped at BreakerMaxCooldown, with jitter.
+// Callers must hold s.Lock().
+func (s *Server) tripBreaker() {
+	if s.BreakerState != BreakerOpen {
+		s.BreakerTrips++
+	}
+	s.BreakerState = BreakerOpen
+	shift := min(s.BreakerTrips-1, maxBreakerBackoffShift)
+	cooldown := s.BreakerBaseCooldown * time.Duration(int64(1)<<uint(shift))
+	if cooldown > s.BreakerMaxCooldown {
+		cooldown = s.BreakerMaxCooldown
+	}
+	jitter := 1 + (rand.Float64()*2-1)*breakerJitter
+	s.BreakerNextProbeAt = time.Now().Add(time.Duration(float64(cooldown) * jitter))
+}
+
+// AllowRequest reports whether the circuit breaker currently permits a
+// request to reach this server. It transitions OPEN -> HALF_OPEN once the
+// cooldown has elapsed, and admits at most BreakerHalfOpenProbes concurrent
+// probes while half-open.
+func (s *Server) AllowRequest() bool {
+	s.Lock()
+	if s.BreakerState == BreakerOpen {
+		if time.Now().Before(s.BreakerNextProbeAt) {
+			s.Unlock()
+			return false
+		}
+		s.BreakerState = BreakerHalfOpen
+		s.BreakerHalfOpenDone = 0
+		atomic.StoreInt32(&s.halfOpenInFlight, 0)
+	}
+	halfOpen := s.BreakerState == BreakerHalfOpen
+	s.Unlock()
+
+	if !halfOpen {
+		return true
+	}
+	if atomic.AddInt32(&s.halfOpenInFlight, 1) > int32(s.BreakerHalfOpenProbes) {
+		atomic.AddInt32(&s.halfOpenInFlight, -1)
+		return false
+	}
+	return true
+}
+
+// finishHalfOpenProbe records the outcome of a request that AllowRequest
+// admitted as a half-open probe. The breaker closes once BreakerHalfOpenProbes
+// probes have all succeeded; any single failure reopens it with an
+// incremented trip count. Callers must hold s.Lock().
+func (s *Server) finishHalfOpenProbe(success bool) {
+	atomic.AddInt32(&s.halfOpenInFlight, -1)
+	if !success {
+		s.tripBreaker()
+		return
+	}
+	s.BreakerHalfOpenDone++
+	if s.BreakerHalfOpenDone >= s.BreakerHalfOpenProbes {
+		s.BreakerState = BreakerClosed
+		s.BreakerTrips = 0
+	}
+}
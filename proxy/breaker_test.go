@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newBreakerTestServer() *Server {
+	return &Server{
+		RequestErrorThreshold: 1,
+		BreakerState:          BreakerClosed,
+		BreakerBaseCooldown:   10 * time.Millisecond,
+		BreakerMaxCooldown:    time.Second,
+		BreakerHalfOpenProbes: 2,
+	}
+}
+
+func TestServer_handleRequestError_TripsBreaker(t *testing.T) {
+	s := newBreakerTestServer()
+	s.handleRequestError()
+
+	if s.BreakerState != BreakerOpen {
+		t.Fatalf("BreakerState = %s, want OPEN after crossing RequestErrorThreshold", s.BreakerState)
+	}
+	if s.AllowRequest() {
+		t.Error("AllowRequest() = true, want false while cooldown hasn't elapsed")
+	}
+}
+
+func TestServer_AllowRequest_TransitionsToHalfOpenAfterCooldown(t *testing.T) {
+	s := newBreakerTestServer()
+	s.handleRequestError()
+	time.Sleep(20 * time.Millisecond)
+
+	if !s.AllowRequest() {
+		t.Fatal("AllowRequest() = false, want true once cooldown elapsed (first probe)")
+	}
+	if s.BreakerState != BreakerHalfOpen {
+		t.Errorf("BreakerState = %s, want HALF_OPEN", s.BreakerState)
+	}
+}
+
+func TestServer_AllowRequest_LimitsConcurrentHalfOpenProbes(t *testing.T) {
+	s := newBreakerTestServer()
+	s.handleRequestError()
+	time.Sleep(20 * time.Millisecond)
+
+	admitted := 0
+	for i := 0; i < 5; i++ {
+		if s.AllowRequest() {
+			admitted++
+		}
+	}
+	if admitted != s.BreakerHalfOpenProbes {
+		t.Errorf("admitted = %d, want %d (BreakerHalfOpenProbes)", admitted, s.BreakerHalfOpenProbes)
+	}
+}
+
+func TestServer_handleRequestSuccess_ClosesBreakerAfterAllProbesSucceed(t *testing.T) {
+	s := newBreakerTestServer()
+	s.handleRequestError()
+	time.Sleep(20 * time.Millisecond)
+
+	s.AllowRequest()
+	s.AllowRequest()
+	s.handleRequestSuccess()
+	s.handleRequestSuccess()
+
+	if s.BreakerState != BreakerClosed {
+		t.Errorf("BreakerState = %s, want CLOSED after every half-open probe succeeded", s.BreakerState)
+	}
+	if s.BreakerTrips != 0 {
+		t.Errorf("BreakerTrips = %d, want 0 after closing", s.BreakerTrips)
+	}
+}
+
+func TestServer_handleRequestError_ReopensOnFailedProbe(t *testing.T) {
+	s := newBreakerTestServer()
+	s.handleRequestError()
+	time.Sleep(20 * time.Millisecond)
+
+	s.AllowRequest()
+	s.handleRequestError()
+
+	if s.BreakerState != BreakerOpen {
+		t.Errorf("BreakerState = %s, want OPEN after a failed half-open probe", s.BreakerState)
+	}
+	if s.BreakerTrips != 2 {
+		t.Errorf("BreakerTrips = %d, want 2 (incremented on re-trip)", s.BreakerTrips)
+	}
+}
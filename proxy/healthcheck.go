@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"service-discovery/shared"
+	"sync"
+	"time"
+)
+
+// HealthChecker periodically probes every registered server and updates its
+// status, replacing the load balancer's old fixed 5s http.Get ticker.
+type HealthChecker struct {
+	Interval            time.Duration
+	Timeout             time.Duration
+	Path                string
+	Method              string
+	AcceptedStatusCodes []int
+	UnhealthyThreshold  int
+	HealthyThreshold    int
+
+	client http.Client
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewHealthChecker builds a HealthChecker with the given defaults. Individual
+// servers may override Path, Method, Interval, Timeout and
+// AcceptedStatusCodes at registration time (see applyHealthCheckOverrides).
+func NewHealthChecker(interval, timeout time.Duration, path, method string, acceptedStatusCodes []int, unhealthyThreshold, healthyThreshold int) *HealthChecker {
+	return &HealthChecker{
+		Interval:            interval,
+		Timeout:             timeout,
+		Path:                path,
+		Method:              method,
+		AcceptedStatusCodes: acceptedStatusCodes,
+		UnhealthyThreshold:  unhealthyThreshold,
+		HealthyThreshold:    healthyThreshold,
+		client:              http.Client{Timeout: timeout},
+		stop:                make(chan struct{}),
+	}
+}
+
+// Run launches a goroutine that probes every server in lb immediately, then
+// again on every tick of Interval, until Stop is called. wg.Add happens here,
+// before the goroutine starts, so Stop's wg.Wait is guaranteed to observe it
+// regardless of scheduling.
+func (hc *HealthChecker) Run(lb *LoadBalancer) {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		hc.run(lb)
+	}()
+}
+
+// run is Run's loop body.
+func (hc *HealthChecker) run(lb *LoadBalancer) {
+	hc.probeAll(lb)
+
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			hc.probeAll(lb)
+		case <-hc.stop:
+			return
+		}
+	}
+}
+
+// Stop signals Run to return and blocks until it, and any probe still in
+// flight, have finished. Safe to call once.
+func (hc *HealthChecker) Stop() {
+	close(hc.stop)
+	hc.wg.Wait()
+}
+
+func (hc *HealthChecker) probeAll(lb *LoadBalancer) {
+	for _, server := range lb.Servers {
+		interval := hc.Interval
+		if server.HealthCheckInterval > 0 {
+			interval = server.HealthCheckInterval
+		}
+		if !server.dueForHealthCheck(interval) {
+			continue
+		}
+		hc.wg.Add(1)
+		go func(server *Server) {
+			defer hc.wg.Done()
+			hc.probe(server, lb.Tracer, lb.Metrics)
+		}(server)
+	}
+}
+
+// probe runs a single health check against server, applying any per-server
+// overrides on top of the HealthChecker's defaults, and reports the outcome
+// to tracer and metrics (either of which may be nil, e.g. in tests).
+func (hc *HealthChecker) probe(server *Server, tracer *Tracer, metrics *Metrics) {
+	path := server.HealthCheckPath
+	if path == "" {
+		path = hc.Path
+	}
+	method := server.HealthCheckMethod
+	if method == "" {
+		method = hc.Method
+	}
+	timeout := hc.Timeout
+	if server.HealthCheckTimeout > 0 {
+		timeout = server.HealthCheckTimeout
+	}
+	acceptedStatusCodes := hc.AcceptedStatusCodes
+	if len(server.HealthCheckAcceptedStatusCodes) > 0 {
+		acceptedStatusCodes = server.HealthCheckAcceptedStatusCodes
+	}
+
+	startTime := time.Now()
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		server.handleHealthCheckError()
+		hc.emitTrace(tracer, server, startTime, method, path, nil, err)
+		hc.emitMetrics(metrics, server, true)
+		return
+	}
+
+	client := hc.client
+	if server.Client != nil {
+		client = *server.Client
+	}
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		server.handleHealthCheckError()
+		hc.logIfContinuouslyFailing(server, err.Error())
+		hc.emitTrace(tracer, server, startTime, method, path, resp, err)
+		hc.emitMetrics(metrics, server, true)
+		return
+	}
+	defer resp.Body.Close()
+
+	if !acceptStatusCode(resp.StatusCode, acceptedStatusCodes) {
+		server.handleHealthCheckError()
+		hc.logIfContinuouslyFailing(server, "unaccepted status code "+http.StatusText(resp.StatusCode))
+		hc.emitTrace(tracer, server, startTime, method, path, resp, fmt.Errorf("unaccepted status code %d", resp.StatusCode))
+		hc.emitMetrics(metrics, server, true)
+		return
+	}
+	server.handleHealthCheckSuccess()
+	hc.emitTrace(tracer, server, startTime, method, path, resp, nil)
+	hc.emitMetrics(metrics, server, false)
+}
+
+func (hc *HealthChecker) emitTrace(tracer *Tracer, server *Server, startTime time.Time, method, path string, resp *http.Response, probeErr error) {
+	if tracer == nil {
+		return
+	}
+	info := TraceInfo{
+		Kind:       TraceKindHealth,
+		Timestamp:  startTime,
+		NodeURL:    server.URL,
+		Method:     method,
+		Path:       path,
+		StatusCode: responseStatusCode(resp),
+		LatencyMs:  time.Since(startTime).Milliseconds(),
+	}
+	if probeErr != nil {
+		info.Error = probeErr.Error()
+	}
+	tracer.Emit(info)
+}
+
+// emitMetrics updates the Prometheus collectors for a single probe outcome.
+// metrics may be nil, e.g. in tests.
+func (hc *HealthChecker) emitMetrics(metrics *Metrics, server *Server, failed bool) {
+	if metrics == nil {
+		return
+	}
+	if failed {
+		metrics.recordHealthCheckFailure(server.URL)
+	}
+	metrics.syncServerGauges(server)
+}
+
+func (hc *HealthChecker) logIfContinuouslyFailing(server *Server, reason string) {
+	if server.HealthCheckErrorCount >= hc.UnhealthyThreshold {
+		log.Printf("WARN: health check for %s failing continuously: %s", server.URL, reason)
+	}
+}
+
+func acceptStatusCode(statusCode int, accepted []int) bool {
+	for _, code := range accepted {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHealthCheckOverrides copies per-server health check overrides from a
+// /register payload onto the freshly assigned Server.
+func applyHealthCheckOverrides(server *Server, newServer *shared.NewServer) {
+	server.HealthCheckPath = newServer.HealthCheckPath
+	server.HealthCheckMethod = newServer.HealthCheckMethod
+	if newServer.HealthCheckIntervalMs > 0 {
+		server.HealthCheckInterval = time.Duration(newServer.HealthCheckIntervalMs) * time.Millisecond
+	}
+	if newServer.HealthCheckTimeoutMs > 0 {
+		server.HealthCheckTimeout = time.Duration(newServer.HealthCheckTimeoutMs) * time.Millisecond
+	}
+	server.HealthCheckAcceptedStatusCodes = newServer.HealthCheckAcceptedStatusCodes
+	if newServer.UnhealthyThreshold > 0 {
+		server.HealthCheckErrorThreshold = newServer.UnhealthyThreshold
+	}
+	if newServer.HealthyThreshold > 0 {
+		server.HealthyThreshold = newServer.HealthyThreshold
+	}
+}
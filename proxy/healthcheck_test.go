@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker_probe_marksDownAfterThreshold(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	hc := NewHealthChecker(time.Second, time.Second, "/healthcheck", http.MethodGet, []int{http.StatusOK}, 2, 1)
+	server := &Server{URL: ts.URL, HealthCheckErrorThreshold: 2, Status: ServerStatusHEALTHY}
+
+	hc.probe(server, nil, nil)
+	if server.Status != ServerStatusHEALTHY {
+		t.Errorf("Status after 1 failure = %s, want HEALTHY (threshold not reached)", server.Status)
+	}
+	hc.probe(server, nil, nil)
+	if server.Status != ServerStatusDOWN {
+		t.Errorf("Status after 2 failures = %s, want DOWN", server.Status)
+	}
+}
+
+func TestHealthChecker_probe_recoversAfterHealthyThreshold(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	hc := NewHealthChecker(time.Second, time.Second, "/healthcheck", http.MethodGet, []int{http.StatusOK}, 2, 2)
+	server := &Server{URL: ts.URL, HealthyThreshold: 2, Status: ServerStatusDOWN}
+
+	hc.probe(server, nil, nil)
+	if server.Status != ServerStatusDOWN {
+		t.Errorf("Status after 1 success = %s, want DOWN (healthy threshold not reached)", server.Status)
+	}
+	hc.probe(server, nil, nil)
+	if server.Status != ServerStatusHEALTHY {
+		t.Errorf("Status after 2 successes = %s, want HEALTHY", server.Status)
+	}
+}
+
+func TestHealthChecker_Stop_waitsForInFlightProbe(t *testing.T) {
+	probeStarted := make(chan struct{})
+	allowProbeToFinish := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(probeStarted)
+		<-allowProbeToFinish
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// A long Interval means only the immediate startup probe runs during
+	// this test; the ticker never fires.
+	hc := NewHealthChecker(time.Hour, time.Second, "/healthcheck", http.MethodGet, []int{http.StatusOK}, 2, 1)
+	lb := &LoadBalancer{Servers: []*Server{{URL: ts.URL, Status: ServerStatusHEALTHY}}}
+
+	hc.Run(lb)
+
+	select {
+	case <-probeStarted:
+	case <-time.After(time.Second):
+		t.Fatal("probe never started")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		hc.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop() returned while a probe was still in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(allowProbeToFinish)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() did not return after the in-flight probe finished")
+	}
+}
+
+func TestServer_dueForHealthCheck(t *testing.T) {
+	server := &Server{}
+	if !server.dueForHealthCheck(time.Minute) {
+		t.Error("dueForHealthCheck() = false on first call, want true (probe immediately on startup)")
+	}
+	if server.dueForHealthCheck(time.Minute) {
+		t.Error("dueForHealthCheck() = true right after a probe, want false (interval not elapsed)")
+	}
+}
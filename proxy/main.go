@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -14,17 +15,34 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var (
+	strategyName   string
+	stickySession  bool
+	hashHeaderName string
+)
+
 func main() {
+	// Assign balancing strategy, with round_robin as the default
+	flag.StringVar(&strategyName, "strategy", "round_robin", "balancing strategy: round_robin, weighted_random, random, ip_hash, header_hash")
+	flag.BoolVar(&stickySession, "sticky", false, "pin clients to the server they were first routed to")
+	flag.StringVar(&hashHeaderName, "hash-header", "X-Sticky-Key", "request header used by the header_hash strategy")
+	flag.Parse()
+
 	// Initiate load balancer handler
 	lb := NewLoadBalancer()
+	lb.StickySession = stickySession
+	lb.HashHeaderName = hashHeaderName
+	lb.Strategy = NewBalancingStrategy(strategyName, lb.NormalWeight, lb.SlowWeight, stickySession, hashHeaderName)
 
 	// Initiate healthcheck ticker
-	go lb.healthCheck()
+	lb.HealthChecker.Run(lb)
 	e := echo.New()
 
 	// Registered routes
 	e.PUT("/reset", lb.ResetStatsHandler)
 	e.GET("/stats", lb.StatsHandler)
+	e.GET("/trace", lb.TraceHandler)
+	e.GET("/metrics", lb.MetricsHandler)
 	e.POST("/register", lb.RegisterServerHandler)
 	e.Any("/*", lb.ProxyHandler)
 
@@ -49,18 +67,45 @@ type Server struct {
 	URL                       string
 	RequestErrorThreshold     int
 	HealthCheckErrorThreshold int
+	HealthyThreshold          int
 	SlowRequestThreshold      int
 
+	// Per-server health check overrides; zero values mean "use the
+	// HealthChecker's default".
+	HealthCheckPath                string
+	HealthCheckMethod              string
+	HealthCheckInterval            time.Duration
+	HealthCheckTimeout             time.Duration
+	HealthCheckAcceptedStatusCodes []int
+
+	// Circuit breaker tuning, copied from the LoadBalancer at registration.
+	BreakerBaseCooldown   time.Duration
+	BreakerMaxCooldown    time.Duration
+	BreakerHalfOpenProbes int
+
+	// Client is the http.Client used to reach this server, built once at
+	// registration from its TLS settings (if any) and reused by both
+	// ProxyHandler and the HealthChecker.
+	Client *http.Client
+
 	// Field that can be mutated each request
 	sync.Mutex
-	HitCount              decimal.Decimal
-	Weight                int
-	HealthCheckErrorCount int
-	RequestErrorCount     int
-	SlowRequestCount      int
-	Status                ServerStatus
-	RecoverTime           time.Time
-	AverageLatency        decimal.Decimal
+	HitCount                decimal.Decimal
+	Weight                  int
+	HealthCheckErrorCount   int
+	HealthCheckSuccessCount int
+	RequestErrorCount       int
+	SlowRequestCount        int
+	Status                  ServerStatus
+	AverageLatency          decimal.Decimal
+	lastHealthCheckAt       time.Time
+
+	// Circuit breaker state, see breaker.go.
+	BreakerState        BreakerState
+	BreakerTrips        int
+	BreakerNextProbeAt  time.Time
+	BreakerHalfOpenDone int
+	halfOpenInFlight    int32
 }
 
 // Handle if request to application instance is success
@@ -69,16 +114,24 @@ func (s *Server) handleRequestSuccess() {
 	defer s.Unlock()
 	s.RequestErrorCount = 0
 	s.Status = ServerStatusHEALTHY
+	if s.BreakerState == BreakerHalfOpen {
+		s.finishHalfOpenProbe(true)
+	}
 }
 
 // Handle if request to application instance is error
 func (s *Server) handleRequestError() {
 	s.Lock()
 	defer s.Unlock()
+	if s.BreakerState == BreakerHalfOpen {
+		s.finishHalfOpenProbe(false)
+	}
 	s.RequestErrorCount += 1
 	if s.RequestErrorCount >= s.RequestErrorThreshold {
 		s.Status = ServerStatusUNHEALTHY
-		s.RecoverTime = time.Now().Add(30 * time.Second)
+		if s.BreakerState != BreakerOpen {
+			s.tripBreaker()
+		}
 	}
 }
 
@@ -86,9 +139,14 @@ func (s *Server) handleRequestError() {
 func (s *Server) handleHealthCheckSuccess() {
 	s.Lock()
 	defer s.Unlock()
-	if s.Status == ServerStatusDOWN {
+	s.HealthCheckErrorCount = 0
+	if s.Status != ServerStatusDOWN {
+		return
+	}
+	s.HealthCheckSuccessCount += 1
+	if s.HealthCheckSuccessCount >= s.HealthyThreshold {
 		s.Status = ServerStatusHEALTHY
-		s.HealthCheckErrorCount = 0
+		s.HealthCheckSuccessCount = 0
 	}
 }
 
@@ -96,34 +154,66 @@ func (s *Server) handleHealthCheckSuccess() {
 func (s *Server) handleHealthCheckError() {
 	s.Lock()
 	defer s.Unlock()
+	s.HealthCheckSuccessCount = 0
 	s.HealthCheckErrorCount += 1
 	if s.HealthCheckErrorCount >= s.HealthCheckErrorThreshold {
 		s.Status = ServerStatusDOWN
 	}
 }
 
+// dueForHealthCheck reports whether interval has elapsed since the server's
+// last probe, and if so marks it as probed now.
+func (s *Server) dueForHealthCheck(interval time.Duration) bool {
+	s.Lock()
+	defer s.Unlock()
+	if time.Since(s.lastHealthCheckAt) < interval {
+		return false
+	}
+	s.lastHealthCheckAt = time.Now()
+	return true
+}
+
 // Load balancer need collection of servers
 type LoadBalancer struct {
 	sync.Mutex
-	Client          http.Client
-	Servers         []*Server
-	Counter         int
-	WeightCounter   int
-	NormalWeight    int
-	SlowWeight      int
-	TotalHit        decimal.Decimal
-	AverageLatency  decimal.Decimal
-	ExpectedLatency decimal.Decimal
+	Servers               []*Server
+	Strategy              BalancingStrategy
+	HealthChecker         *HealthChecker
+	Tracer                *Tracer
+	Metrics               *Metrics
+	Counter               int
+	WeightCounter         int
+	NormalWeight          int
+	SlowWeight            int
+	TotalHit              decimal.Decimal
+	AverageLatency        decimal.Decimal
+	ExpectedLatency       decimal.Decimal
+	BreakerBaseCooldown   time.Duration
+	BreakerMaxCooldown    time.Duration
+	BreakerHalfOpenProbes int
+
+	// StickySession and HashHeaderName mirror the -sticky and -hash-header
+	// flags. They're kept around so assignServer can rebuild Strategy with
+	// the same wrapping when a /register call supplies its own Strategy.
+	StickySession  bool
+	HashHeaderName string
 }
 
 // Initiate LoadBalancer class
 func NewLoadBalancer() *LoadBalancer {
 	return &LoadBalancer{
-		Servers:         []*Server{},
-		WeightCounter:   2,
-		NormalWeight:    2,
-		SlowWeight:      1,
-		ExpectedLatency: decimal.NewFromInt(100),
+		Servers:               []*Server{},
+		WeightCounter:         2,
+		NormalWeight:          2,
+		SlowWeight:            1,
+		ExpectedLatency:       decimal.NewFromInt(100),
+		Strategy:              NewRoundRobinStrategy(2, 1),
+		HealthChecker:         NewHealthChecker(5*time.Second, 2*time.Second, "/healthcheck", http.MethodGet, []int{http.StatusOK}, 2, 1),
+		Tracer:                NewTracer(1000),
+		Metrics:               NewMetrics(),
+		BreakerBaseCooldown:   2 * time.Second,
+		BreakerMaxCooldown:    60 * time.Second,
+		BreakerHalfOpenProbes: 1,
 	}
 }
 
@@ -133,7 +223,16 @@ func (lb *LoadBalancer) ProxyHandler(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusRequestTimeout, "No healthy server")
 	}
 	// Select the server
-	server := lb.selectServer()
+	server := lb.selectServer(ctx.Request())
+	if server == nil {
+		return echo.NewHTTPError(http.StatusRequestTimeout, "No healthy server")
+	}
+	if !server.AllowRequest() {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Circuit breaker open for backend server")
+	}
+	if sticky, ok := lb.Strategy.(*StickySession); ok {
+		ctx.SetCookie(&http.Cookie{Name: sticky.CookieName, Value: server.URL})
+	}
 
 	// Construct request from selected server
 	reqUrl := fmt.Sprintf("%s%s?%s", server.URL, ctx.Request().URL.Path, ctx.Request().URL.RawQuery)
@@ -145,12 +244,25 @@ func (lb *LoadBalancer) ProxyHandler(ctx echo.Context) error {
 
 	// Call and track the request latency
 	startTime := time.Now()
-	resp, err := lb.Client.Do(req)
+	resp, err := server.Client.Do(req)
 	duration := time.Since(startTime)
 	lb.processLatency(duration, server)
 	if err != nil || resp.StatusCode != 200 {
 		// Check if the error is a timeout
 		server.handleRequestError()
+		lb.Metrics.observeRequest(server.URL, "error")
+		lb.Tracer.Emit(TraceInfo{
+			Kind:       TraceKindCall,
+			Timestamp:  startTime,
+			NodeURL:    server.URL,
+			Method:     ctx.Request().Method,
+			Path:       ctx.Request().URL.Path,
+			ReqHeaders: redactHeaders(ctx.Request().Header),
+			StatusCode: responseStatusCode(resp),
+			LatencyMs:  duration.Milliseconds(),
+			BytesIn:    ctx.Request().ContentLength,
+			Error:      traceErrorMessage(err, resp),
+		})
 		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
 			return echo.NewHTTPError(http.StatusRequestTimeout, "Request to backend server timed out")
 		}
@@ -158,6 +270,7 @@ func (lb *LoadBalancer) ProxyHandler(ctx echo.Context) error {
 	}
 	defer resp.Body.Close()
 	server.handleRequestSuccess()
+	lb.Metrics.observeRequest(server.URL, "success")
 
 	// Copy the response from the backend server to the original client
 	for key, values := range resp.Header {
@@ -166,7 +279,20 @@ func (lb *LoadBalancer) ProxyHandler(ctx echo.Context) error {
 		}
 	}
 	ctx.Response().WriteHeader(resp.StatusCode)
-	_, err = io.Copy(ctx.Response().Writer, resp.Body)
+	bytesOut, err := io.Copy(ctx.Response().Writer, resp.Body)
+	lb.Tracer.Emit(TraceInfo{
+		Kind:        TraceKindCall,
+		Timestamp:   startTime,
+		NodeURL:     server.URL,
+		Method:      ctx.Request().Method,
+		Path:        ctx.Request().URL.Path,
+		ReqHeaders:  redactHeaders(ctx.Request().Header),
+		RespHeaders: redactHeaders(resp.Header),
+		StatusCode:  resp.StatusCode,
+		LatencyMs:   duration.Milliseconds(),
+		BytesIn:     ctx.Request().ContentLength,
+		BytesOut:    bytesOut,
+	})
 	if err != nil {
 		return err
 	}
@@ -174,26 +300,31 @@ func (lb *LoadBalancer) ProxyHandler(ctx echo.Context) error {
 	return nil
 }
 
-// selectServer choose a server using round robin algorithm
-func (lb *LoadBalancer) selectServer() *Server {
+// responseStatusCode safely reads a status code off a response that may be nil.
+func responseStatusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// traceErrorMessage describes why a proxied call failed, for TraceInfo.Error.
+func traceErrorMessage(err error, resp *http.Response) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("unexpected status code %d", responseStatusCode(resp))
+}
+
+// selectServer delegates to the configured BalancingStrategy and tracks hit counts
+func (lb *LoadBalancer) selectServer(r *http.Request) *Server {
 	// Lock to handle concurrent increment
 	lb.Lock()
 	defer lb.Unlock()
 
-	// Select the server based on the current state
-	var server *Server
-
-	// Reselect server if it's down or unhealthy but still in recover period
-	for server == nil || server.Weight < lb.WeightCounter || server.Status == ServerStatusDOWN || (server.Status == ServerStatusUNHEALTHY && time.Now().Before(server.RecoverTime)) {
-		idx := lb.Counter % len(lb.Servers)
-		if idx == 0 {
-			lb.WeightCounter--
-			if lb.WeightCounter < 1 {
-				lb.WeightCounter = lb.NormalWeight
-			}
-		}
-		server = lb.Servers[idx]
-		lb.Counter++
+	server := lb.Strategy.Pick(lb.Servers, r)
+	if server == nil {
+		return nil
 	}
 	server.HitCount = server.HitCount.Add(decimal.NewFromInt(1))
 	lb.TotalHit = lb.TotalHit.Add(decimal.NewFromInt(1))
@@ -207,54 +338,57 @@ func (lb *LoadBalancer) RegisterServerHandler(ctx echo.Context) error {
 	if err := ctx.Bind(newServer); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to bind request")
 	}
-	lb.assignServer(newServer)
+	if err := lb.assignServer(newServer); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 	ctx.Response().WriteHeader(200)
 	return nil
 }
 
-// assignServer mutate the LoadBalancer object by appending new server
-func (lb *LoadBalancer) assignServer(newServer *shared.NewServer) {
+// assignServer mutate the LoadBalancer object by appending new server. It
+// fails if newServer carries malformed TLS material.
+func (lb *LoadBalancer) assignServer(newServer *shared.NewServer) error {
 	lb.Lock()
 	defer lb.Unlock()
+	if newServer.Strategy != "" {
+		lb.Strategy = NewBalancingStrategy(newServer.Strategy, lb.NormalWeight, lb.SlowWeight, lb.StickySession, lb.HashHeaderName)
+	}
+	weight := lb.NormalWeight
+	if newServer.Weight > 0 {
+		weight = newServer.Weight
+	}
 	for _, server := range lb.Servers {
 		if server.URL == newServer.URL {
 			server.Status = ServerStatusHEALTHY
-			return
+			server.Weight = weight
+			return nil
 		}
 	}
-	lb.Servers = append(lb.Servers, &Server{
+	client, err := buildServerClient(newServer)
+	if err != nil {
+		return err
+	}
+	server := &Server{
 		URL:                       newServer.URL,
 		RequestErrorThreshold:     2,
 		HealthCheckErrorThreshold: 2,
+		HealthyThreshold:          1,
 		SlowRequestThreshold:      2,
 		Status:                    ServerStatusHEALTHY,
-		Weight:                    lb.NormalWeight,
-	})
-}
-
-// Run ticker to frequently check the healthcheck of registered servers
-func (lb *LoadBalancer) healthCheck() {
-	// Ticker that check every 5 seconds
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			for _, server := range lb.Servers {
-				go doHealthCheck(server)
-			}
-		}
+		Weight:                    weight,
+		BreakerState:              BreakerClosed,
+		BreakerBaseCooldown:       lb.BreakerBaseCooldown,
+		BreakerMaxCooldown:        lb.BreakerMaxCooldown,
+		BreakerHalfOpenProbes:     lb.BreakerHalfOpenProbes,
+		Client:                    client,
 	}
-}
-
-// Execute the health check
-func doHealthCheck(server *Server) {
-	resp, err := http.Get(server.URL + "/healthcheck")
-	if err != nil || resp.StatusCode != http.StatusOK {
-		server.handleHealthCheckError()
-		return
+	if lb.HealthChecker != nil {
+		server.HealthyThreshold = lb.HealthChecker.HealthyThreshold
+		server.HealthCheckErrorThreshold = lb.HealthChecker.UnhealthyThreshold
 	}
-	server.handleHealthCheckSuccess()
+	applyHealthCheckOverrides(server, newServer)
+	lb.Servers = append(lb.Servers, server)
+	return nil
 }
 
 // StatsHandler return the internal statistics of the server collection
@@ -264,13 +398,18 @@ func (lb *LoadBalancer) StatsHandler(ctx echo.Context) error {
 	res["total_avg_latency"] = lb.AverageLatency
 	serverStats := map[string]interface{}{}
 	for _, server := range lb.Servers {
-		waitTime := time.Until(server.RecoverTime).Seconds()
+		waitTime := time.Until(server.BreakerNextProbeAt).Seconds()
 		serverStats[server.URL] = map[string]interface{}{
 			"status":    server.Status,
 			"hit_count": server.HitCount,
 			"avg_lat":   server.AverageLatency,
 			"weight":    server.Weight,
 			"wait":      max(0, waitTime),
+			"breaker": map[string]interface{}{
+				"state":         server.BreakerState,
+				"trips":         server.BreakerTrips,
+				"next_probe_at": server.BreakerNextProbeAt,
+			},
 		}
 	}
 	res["servers"] = serverStats
@@ -286,12 +425,23 @@ func (lb *LoadBalancer) ResetStatsHandler(ctx echo.Context) error {
 	lb.AverageLatency = decimal.Decimal{}
 	for _, server := range servers {
 		lb.Servers = append(lb.Servers, &Server{
-			URL:                       server.URL,
-			RequestErrorThreshold:     2,
-			HealthCheckErrorThreshold: 2,
-			SlowRequestThreshold:      2,
-			Status:                    ServerStatusHEALTHY,
-			Weight:                    lb.NormalWeight,
+			URL:                            server.URL,
+			RequestErrorThreshold:          2,
+			HealthCheckErrorThreshold:      server.HealthCheckErrorThreshold,
+			HealthyThreshold:               server.HealthyThreshold,
+			SlowRequestThreshold:           2,
+			Status:                         ServerStatusHEALTHY,
+			Weight:                         lb.NormalWeight,
+			BreakerState:                   BreakerClosed,
+			BreakerBaseCooldown:            server.BreakerBaseCooldown,
+			BreakerMaxCooldown:             server.BreakerMaxCooldown,
+			BreakerHalfOpenProbes:          server.BreakerHalfOpenProbes,
+			Client:                         server.Client,
+			HealthCheckPath:                server.HealthCheckPath,
+			HealthCheckMethod:              server.HealthCheckMethod,
+			HealthCheckInterval:            server.HealthCheckInterval,
+			HealthCheckTimeout:             server.HealthCheckTimeout,
+			HealthCheckAcceptedStatusCodes: server.HealthCheckAcceptedStatusCodes,
 		})
 	}
 	return nil
@@ -303,13 +453,16 @@ func (lb *LoadBalancer) processLatency(latency time.Duration, server *Server) {
 	defer lb.Unlock()
 	lb.AverageLatency = lb.AverageLatency.Mul(lb.TotalHit.Sub(decimal.NewFromInt(1))).Add(decimal.NewFromInt(latency.Milliseconds())).Div(lb.TotalHit)
 	server.AverageLatency = server.AverageLatency.Mul(server.HitCount.Sub(decimal.NewFromInt(1))).Add(decimal.NewFromInt(latency.Milliseconds())).Div(server.HitCount)
+	lb.Metrics.observeLatency(server.URL, latency.Seconds())
 	if decimal.NewFromInt(latency.Milliseconds()).LessThanOrEqual(lb.ExpectedLatency) {
 		server.SlowRequestCount = 0
 		server.Weight = lb.NormalWeight
+		lb.Metrics.syncServerGauges(server)
 		return
 	}
 	server.SlowRequestCount += 1
 	if server.SlowRequestCount > server.SlowRequestThreshold {
 		server.Weight = 1
 	}
+	lb.Metrics.syncServerGauges(server)
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"net/http"
 	"reflect"
+	"service-discovery/shared"
 	"sync"
 	"testing"
 	"time"
@@ -22,7 +24,6 @@ func TestServer_handleRequestSuccess(t *testing.T) {
 		RequestErrorCount         int
 		SlowRequestCount          int
 		Status                    ServerStatus
-		RecoverTime               time.Time
 		AverageLatency            decimal.Decimal
 	}
 	tests := []struct {
@@ -50,7 +51,6 @@ func TestServer_handleRequestSuccess(t *testing.T) {
 				RequestErrorCount:         tt.fields.RequestErrorCount,
 				SlowRequestCount:          tt.fields.SlowRequestCount,
 				Status:                    tt.fields.Status,
-				RecoverTime:               tt.fields.RecoverTime,
 				AverageLatency:            tt.fields.AverageLatency,
 			}
 			s.handleRequestSuccess()
@@ -77,7 +77,6 @@ func TestServer_handleRequestError(t *testing.T) {
 		RequestErrorCount         int
 		SlowRequestCount          int
 		Status                    ServerStatus
-		RecoverTime               time.Time
 		AverageLatency            decimal.Decimal
 	}
 	tests := []struct {
@@ -106,7 +105,6 @@ func TestServer_handleRequestError(t *testing.T) {
 				RequestErrorCount:         tt.fields.RequestErrorCount,
 				SlowRequestCount:          tt.fields.SlowRequestCount,
 				Status:                    tt.fields.Status,
-				RecoverTime:               tt.fields.RecoverTime,
 				AverageLatency:            tt.fields.AverageLatency,
 			}
 			s.handleRequestError()
@@ -116,8 +114,11 @@ func TestServer_handleRequestError(t *testing.T) {
 			if s.Status != ServerStatusUNHEALTHY {
 				t.Errorf("field Status error, want: UNHEALTHY, got: %s", s.Status)
 			}
-			if s.RecoverTime.IsZero() {
-				t.Errorf("field Status error, want: %s, got: nil", s.RecoverTime.String())
+			if s.BreakerState != BreakerOpen {
+				t.Errorf("field BreakerState error, want: OPEN, got: %s", s.BreakerState)
+			}
+			if s.BreakerNextProbeAt.IsZero() {
+				t.Errorf("field BreakerNextProbeAt error, want: non-zero, got: zero")
 			}
 		})
 	}
@@ -136,7 +137,6 @@ func TestServer_handleHealthCheckSuccess(t *testing.T) {
 		RequestErrorCount         int
 		SlowRequestCount          int
 		Status                    ServerStatus
-		RecoverTime               time.Time
 		AverageLatency            decimal.Decimal
 	}
 	tests := []struct {
@@ -165,7 +165,6 @@ func TestServer_handleHealthCheckSuccess(t *testing.T) {
 				RequestErrorCount:         tt.fields.RequestErrorCount,
 				SlowRequestCount:          tt.fields.SlowRequestCount,
 				Status:                    tt.fields.Status,
-				RecoverTime:               tt.fields.RecoverTime,
 				AverageLatency:            tt.fields.AverageLatency,
 			}
 			s.handleHealthCheckSuccess()
@@ -192,7 +191,6 @@ func TestServer_handleHealthCheckError(t *testing.T) {
 		RequestErrorCount         int
 		SlowRequestCount          int
 		Status                    ServerStatus
-		RecoverTime               time.Time
 		AverageLatency            decimal.Decimal
 	}
 	tests := []struct {
@@ -222,7 +220,6 @@ func TestServer_handleHealthCheckError(t *testing.T) {
 				RequestErrorCount:         tt.fields.RequestErrorCount,
 				SlowRequestCount:          tt.fields.SlowRequestCount,
 				Status:                    tt.fields.Status,
-				RecoverTime:               tt.fields.RecoverTime,
 				AverageLatency:            tt.fields.AverageLatency,
 			}
 			s.handleHealthCheckError()
@@ -244,19 +241,124 @@ func TestNewLoadBalancer(t *testing.T) {
 		{
 			name: "success - normal path",
 			want: &LoadBalancer{
-				Servers:         []*Server{},
-				WeightCounter:   2,
-				NormalWeight:    2,
-				SlowWeight:      1,
-				ExpectedLatency: decimal.NewFromInt(100),
+				Servers:               []*Server{},
+				WeightCounter:         2,
+				NormalWeight:          2,
+				SlowWeight:            1,
+				ExpectedLatency:       decimal.NewFromInt(100),
+				Strategy:              NewRoundRobinStrategy(2, 1),
+				BreakerBaseCooldown:   2 * time.Second,
+				BreakerMaxCooldown:    60 * time.Second,
+				BreakerHalfOpenProbes: 1,
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewLoadBalancer(); !reflect.DeepEqual(got, tt.want) {
+			got := NewLoadBalancer()
+			got.HealthChecker = nil
+			got.Tracer = nil
+			got.Metrics = nil
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewLoadBalancer() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestLoadBalancer_assignServer_appliesHealthCheckOverrides(t *testing.T) {
+	lb := NewLoadBalancer()
+	err := lb.assignServer(&shared.NewServer{
+		URL:                            "http://node-1",
+		HealthCheckPath:                "/ping",
+		HealthCheckMethod:              "HEAD",
+		HealthCheckIntervalMs:          500,
+		HealthCheckTimeoutMs:           250,
+		HealthCheckAcceptedStatusCodes: []int{http.StatusNoContent},
+	})
+	if err != nil {
+		t.Fatalf("assignServer() error = %v", err)
+	}
+	if len(lb.Servers) != 1 {
+		t.Fatalf("len(lb.Servers) = %d, want 1", len(lb.Servers))
+	}
+	server := lb.Servers[0]
+	if server.HealthCheckPath != "/ping" {
+		t.Errorf("HealthCheckPath = %s, want /ping", server.HealthCheckPath)
+	}
+	if server.HealthCheckMethod != "HEAD" {
+		t.Errorf("HealthCheckMethod = %s, want HEAD", server.HealthCheckMethod)
+	}
+	if server.HealthCheckInterval != 500*time.Millisecond {
+		t.Errorf("HealthCheckInterval = %s, want 500ms", server.HealthCheckInterval)
+	}
+	if server.HealthCheckTimeout != 250*time.Millisecond {
+		t.Errorf("HealthCheckTimeout = %s, want 250ms", server.HealthCheckTimeout)
+	}
+	if !reflect.DeepEqual(server.HealthCheckAcceptedStatusCodes, []int{http.StatusNoContent}) {
+		t.Errorf("HealthCheckAcceptedStatusCodes = %v, want [204]", server.HealthCheckAcceptedStatusCodes)
+	}
+}
+
+func TestLoadBalancer_assignServer_appliesStrategyOverride(t *testing.T) {
+	lb := NewLoadBalancer()
+	if _, ok := lb.Strategy.(*RoundRobinStrategy); !ok {
+		t.Fatalf("Strategy before registration = %T, want *RoundRobinStrategy", lb.Strategy)
+	}
+
+	if err := lb.assignServer(&shared.NewServer{URL: "http://node-1", Strategy: "random"}); err != nil {
+		t.Fatalf("assignServer() error = %v", err)
+	}
+
+	if _, ok := lb.Strategy.(*RandomStrategy); !ok {
+		t.Errorf("Strategy after registration = %T, want *RandomStrategy", lb.Strategy)
+	}
+}
+
+func TestLoadBalancer_ResetStatsHandler_preservesHealthCheckOverrides(t *testing.T) {
+	lb := NewLoadBalancer()
+	if err := lb.assignServer(&shared.NewServer{
+		URL:                            "http://node-1",
+		HealthCheckPath:                "/ping",
+		HealthCheckMethod:              "HEAD",
+		HealthCheckIntervalMs:          500,
+		HealthCheckTimeoutMs:           250,
+		HealthCheckAcceptedStatusCodes: []int{http.StatusNoContent},
+	}); err != nil {
+		t.Fatalf("assignServer() error = %v", err)
+	}
+
+	if err := lb.ResetStatsHandler(nil); err != nil {
+		t.Fatalf("ResetStatsHandler() error = %v", err)
+	}
+
+	if len(lb.Servers) != 1 {
+		t.Fatalf("len(lb.Servers) = %d, want 1", len(lb.Servers))
+	}
+	server := lb.Servers[0]
+	if server.HealthCheckPath != "/ping" {
+		t.Errorf("HealthCheckPath after reset = %s, want /ping", server.HealthCheckPath)
+	}
+	if server.HealthCheckMethod != "HEAD" {
+		t.Errorf("HealthCheckMethod after reset = %s, want HEAD", server.HealthCheckMethod)
+	}
+	if server.HealthCheckInterval != 500*time.Millisecond {
+		t.Errorf("HealthCheckInterval after reset = %s, want 500ms", server.HealthCheckInterval)
+	}
+	if server.HealthCheckTimeout != 250*time.Millisecond {
+		t.Errorf("HealthCheckTimeout after reset = %s, want 250ms", server.HealthCheckTimeout)
+	}
+	if !reflect.DeepEqual(server.HealthCheckAcceptedStatusCodes, []int{http.StatusNoContent}) {
+		t.Errorf("HealthCheckAcceptedStatusCodes after reset = %v, want [204]", server.HealthCheckAcceptedStatusCodes)
+	}
+}
+
+func TestNewLoadBalancer_HealthCheckerDefaults(t *testing.T) {
+	lb := NewLoadBalancer()
+	if lb.HealthChecker == nil {
+		t.Fatal("NewLoadBalancer() HealthChecker = nil, want a configured HealthChecker")
+	}
+	if lb.HealthChecker.Path != "/healthcheck" {
+		t.Errorf("HealthChecker.Path = %s, want /healthcheck", lb.HealthChecker.Path)
+	}
+}
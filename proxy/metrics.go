@@ -0,0 +1,114 @@
+package main
+
+import (
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// requestLatencyBuckets mirrors typical proxy latencies, from sub-millisecond
+// to multi-second worst cases.
+var requestLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// allServerStatuses lists every ServerStatus value, used to zero out the
+// inactive states of sd_server_status on every sync.
+var allServerStatuses = []ServerStatus{ServerStatusHEALTHY, ServerStatusUNHEALTHY, ServerStatusDOWN}
+
+// Metrics holds the Prometheus collectors the load balancer exposes on
+// /metrics, registered against a private Registry rather than the global
+// default so multiple LoadBalancer instances in tests don't collide.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	RequestsTotal       *prometheus.CounterVec
+	RequestLatency      *prometheus.HistogramVec
+	HealthCheckFailures *prometheus.CounterVec
+	ServerStatusGauge   *prometheus.GaugeVec
+	ServerWeight        *prometheus.GaugeVec
+	ServerHitCount      *prometheus.GaugeVec
+}
+
+// NewMetrics builds and registers the collectors backing /metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sd_requests_total",
+			Help: "Total proxied requests, by backend server and outcome status.",
+		}, []string{"server", "status"}),
+		RequestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sd_request_latency_seconds",
+			Help:    "Latency of proxied requests to each backend server.",
+			Buckets: requestLatencyBuckets,
+		}, []string{"server"}),
+		HealthCheckFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sd_healthcheck_failures_total",
+			Help: "Total failed health check probes, by backend server.",
+		}, []string{"server"}),
+		ServerStatusGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sd_server_status",
+			Help: "1 if the server is currently in the labeled status, 0 otherwise.",
+		}, []string{"server", "status"}),
+		ServerWeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sd_server_weight",
+			Help: "Current load balancing weight assigned to each backend server.",
+		}, []string{"server"}),
+		ServerHitCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sd_server_hit_count",
+			Help: "Total requests routed to each backend server.",
+		}, []string{"server"}),
+	}
+	m.Registry.MustRegister(
+		m.RequestsTotal,
+		m.RequestLatency,
+		m.HealthCheckFailures,
+		m.ServerStatusGauge,
+		m.ServerWeight,
+		m.ServerHitCount,
+	)
+	return m
+}
+
+// observeRequest records the outcome of a single proxied call; its latency
+// is recorded separately by observeLatency.
+func (m *Metrics) observeRequest(server string, status string) {
+	m.RequestsTotal.WithLabelValues(server, status).Inc()
+}
+
+// observeLatency records how long a proxied call to server took.
+func (m *Metrics) observeLatency(server string, latencySeconds float64) {
+	m.RequestLatency.WithLabelValues(server).Observe(latencySeconds)
+}
+
+// recordHealthCheckFailure increments the failure counter for server.
+func (m *Metrics) recordHealthCheckFailure(server string) {
+	m.HealthCheckFailures.WithLabelValues(server).Inc()
+}
+
+// syncServerGauges refreshes the point-in-time gauges (status, weight, hit
+// count) for server from its current in-memory state. Locks server.Mutex
+// since Status, Weight and HitCount are otherwise guarded by it.
+func (m *Metrics) syncServerGauges(server *Server) {
+	server.Lock()
+	status, weight, hitCountDecimal := server.Status, server.Weight, server.HitCount
+	server.Unlock()
+
+	for _, candidate := range allServerStatuses {
+		value := 0.0
+		if status == candidate {
+			value = 1
+		}
+		m.ServerStatusGauge.WithLabelValues(server.URL, string(candidate)).Set(value)
+	}
+	m.ServerWeight.WithLabelValues(server.URL).Set(float64(weight))
+	hitCount, _ := hitCountDecimal.Float64()
+	m.ServerHitCount.WithLabelValues(server.URL).Set(hitCount)
+}
+
+// MetricsHandler serves the registered collectors in the Prometheus text
+// exposition format for scraping.
+func (lb *LoadBalancer) MetricsHandler(ctx echo.Context) error {
+	handler := promhttp.HandlerFor(lb.Metrics.Registry, promhttp.HandlerOpts{})
+	handler.ServeHTTP(ctx.Response(), ctx.Request())
+	return nil
+}
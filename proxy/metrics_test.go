@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+)
+
+func TestMetrics_observeRequest(t *testing.T) {
+	m := NewMetrics()
+	m.observeRequest("http://node-a", "success")
+	m.observeRequest("http://node-a", "error")
+
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("http://node-a", "success")); got != 1 {
+		t.Errorf("RequestsTotal{success} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("http://node-a", "error")); got != 1 {
+		t.Errorf("RequestsTotal{error} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_recordHealthCheckFailure(t *testing.T) {
+	m := NewMetrics()
+	m.recordHealthCheckFailure("http://node-a")
+	m.recordHealthCheckFailure("http://node-a")
+
+	if got := testutil.ToFloat64(m.HealthCheckFailures.WithLabelValues("http://node-a")); got != 2 {
+		t.Errorf("HealthCheckFailures = %v, want 2", got)
+	}
+}
+
+func TestMetrics_syncServerGauges(t *testing.T) {
+	m := NewMetrics()
+	server := &Server{URL: "http://node-a", Status: ServerStatusHEALTHY, Weight: 2, HitCount: decimal.NewFromInt(5)}
+	m.syncServerGauges(server)
+
+	if got := testutil.ToFloat64(m.ServerStatusGauge.WithLabelValues("http://node-a", string(ServerStatusHEALTHY))); got != 1 {
+		t.Errorf("ServerStatusGauge{HEALTHY} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.ServerStatusGauge.WithLabelValues("http://node-a", string(ServerStatusDOWN))); got != 0 {
+		t.Errorf("ServerStatusGauge{DOWN} = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.ServerWeight.WithLabelValues("http://node-a")); got != 2 {
+		t.Errorf("ServerWeight = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.ServerHitCount.WithLabelValues("http://node-a")); got != 5 {
+		t.Errorf("ServerHitCount = %v, want 5", got)
+	}
+}
+
+// TestMetrics_syncServerGauges_ConcurrentWithServerMutation exercises
+// syncServerGauges racing against the server's own lock holders (as
+// processLatency and the health check handlers do in production) under
+// -race: it must take server.Lock() rather than read Status/Weight/HitCount
+// unguarded.
+func TestMetrics_syncServerGauges_ConcurrentWithServerMutation(t *testing.T) {
+	m := NewMetrics()
+	server := &Server{URL: "http://node-a", RequestErrorThreshold: 2, Status: ServerStatusHEALTHY}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			m.syncServerGauges(server)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			server.handleRequestSuccess()
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BalancingStrategy decides which backend server should handle the next
+// request. Implementations must be safe for concurrent use.
+type BalancingStrategy interface {
+	Pick(servers []*Server, r *http.Request) *Server
+}
+
+// isEligible reports whether a server can currently receive traffic, i.e.
+// it's not DOWN and its circuit breaker isn't OPEN with the cooldown still
+// running.
+func isEligible(server *Server) bool {
+	if server.Status == ServerStatusDOWN {
+		return false
+	}
+	if server.BreakerState == BreakerOpen && time.Now().Before(server.BreakerNextProbeAt) {
+		return false
+	}
+	return true
+}
+
+// eligibleServers filters servers down to the ones a strategy may pick from.
+func eligibleServers(servers []*Server) []*Server {
+	eligible := make([]*Server, 0, len(servers))
+	for _, server := range servers {
+		if isEligible(server) {
+			eligible = append(eligible, server)
+		}
+	}
+	return eligible
+}
+
+// RoundRobinStrategy is the original weighted round-robin behavior: servers
+// are visited in order, with NormalWeight-weighted servers getting an extra
+// pass over SlowWeight-weighted (slow) servers every WeightCounter cycles.
+type RoundRobinStrategy struct {
+	mu            sync.Mutex
+	counter       int
+	weightCounter int
+	NormalWeight  int
+	SlowWeight    int
+}
+
+// NewRoundRobinStrategy builds a RoundRobinStrategy using the given weights.
+func NewRoundRobinStrategy(normalWeight, slowWeight int) *RoundRobinStrategy {
+	return &RoundRobinStrategy{
+		weightCounter: normalWeight,
+		NormalWeight:  normalWeight,
+		SlowWeight:    slowWeight,
+	}
+}
+
+func (s *RoundRobinStrategy) Pick(servers []*Server, r *http.Request) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(eligibleServers(servers)) == 0 {
+		return nil
+	}
+	var server *Server
+	for server == nil || server.Weight < s.weightCounter || !isEligible(server) {
+		idx := s.counter % len(servers)
+		if idx == 0 {
+			s.weightCounter--
+			if s.weightCounter < 1 {
+				s.weightCounter = s.NormalWeight
+			}
+		}
+		server = servers[idx]
+		s.counter++
+	}
+	return server
+}
+
+// WeightedRandomStrategy picks an eligible server at random, with servers
+// weighted by their current Weight field.
+type WeightedRandomStrategy struct{}
+
+func (s *WeightedRandomStrategy) Pick(servers []*Server, r *http.Request) *Server {
+	eligible := eligibleServers(servers)
+	if len(eligible) == 0 {
+		return nil
+	}
+	totalWeight := 0
+	for _, server := range eligible {
+		totalWeight += max(server.Weight, 1)
+	}
+	target := rand.Intn(totalWeight)
+	for _, server := range eligible {
+		target -= max(server.Weight, 1)
+		if target < 0 {
+			return server
+		}
+	}
+	return eligible[len(eligible)-1]
+}
+
+// RandomStrategy picks an eligible server uniformly at random.
+type RandomStrategy struct{}
+
+func (s *RandomStrategy) Pick(servers []*Server, r *http.Request) *Server {
+	eligible := eligibleServers(servers)
+	if len(eligible) == 0 {
+		return nil
+	}
+	return eligible[rand.Intn(len(eligible))]
+}
+
+// IPHashStrategy deterministically maps a client IP to an eligible server,
+// so the same client keeps hitting the same backend as long as it's up.
+type IPHashStrategy struct{}
+
+func (s *IPHashStrategy) Pick(servers []*Server, r *http.Request) *Server {
+	eligible := eligibleServers(servers)
+	if len(eligible) == 0 {
+		return nil
+	}
+	return eligible[fnv32a(clientIP(r))%uint32(len(eligible))]
+}
+
+// clientIP returns the request's remote address without its port.
+func clientIP(r *http.Request) string {
+	host := r.RemoteAddr
+	for i := len(host) - 1; i >= 0; i-- {
+		if host[i] == ':' {
+			return host[:i]
+		}
+	}
+	return host
+}
+
+// HeaderHashStrategy maps the value of a configured request header to an
+// eligible server, e.g. to keep requests from the same tenant together.
+type HeaderHashStrategy struct {
+	Header string
+}
+
+func (s *HeaderHashStrategy) Pick(servers []*Server, r *http.Request) *Server {
+	eligible := eligibleServers(servers)
+	if len(eligible) == 0 {
+		return nil
+	}
+	return eligible[fnv32a(r.Header.Get(s.Header))%uint32(len(eligible))]
+}
+
+// fnv32a hashes s using FNV-1a.
+func fnv32a(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// StickySession decorates another BalancingStrategy so that requests
+// carrying its cookie are routed back to the server that was pinned on the
+// first pick, falling back to the underlying strategy if that server is no
+// longer eligible (or no cookie is present yet).
+type StickySession struct {
+	Underlying BalancingStrategy
+	CookieName string
+}
+
+// NewStickySession wraps underlying with sticky-session routing.
+func NewStickySession(underlying BalancingStrategy, cookieName string) *StickySession {
+	return &StickySession{Underlying: underlying, CookieName: cookieName}
+}
+
+func (s *StickySession) Pick(servers []*Server, r *http.Request) *Server {
+	if cookie, err := r.Cookie(s.CookieName); err == nil {
+		for _, server := range servers {
+			if server.URL == cookie.Value && isEligible(server) {
+				return server
+			}
+		}
+	}
+	return s.Underlying.Pick(servers, r)
+}
+
+// NewBalancingStrategy builds the strategy named by an operator's -strategy
+// flag, optionally wrapped in sticky-session routing.
+func NewBalancingStrategy(name string, normalWeight, slowWeight int, sticky bool, headerName string) BalancingStrategy {
+	var strategy BalancingStrategy
+	switch name {
+	case "weighted_random":
+		strategy = &WeightedRandomStrategy{}
+	case "random":
+		strategy = &RandomStrategy{}
+	case "ip_hash":
+		strategy = &IPHashStrategy{}
+	case "header_hash":
+		strategy = &HeaderHashStrategy{Header: headerName}
+	default:
+		strategy = NewRoundRobinStrategy(normalWeight, slowWeight)
+	}
+	if sticky {
+		return NewStickySession(strategy, "sd_session")
+	}
+	return strategy
+}
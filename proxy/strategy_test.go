@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoundRobinStrategy_Pick(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Weight: 2, Status: ServerStatusHEALTHY},
+		{URL: "b", Weight: 2, Status: ServerStatusHEALTHY},
+	}
+	strategy := NewRoundRobinStrategy(2, 1)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	first := strategy.Pick(servers, r)
+	second := strategy.Pick(servers, r)
+	if first == second {
+		t.Errorf("Pick() returned the same server twice in a row, want round robin alternation")
+	}
+}
+
+func TestRoundRobinStrategy_Pick_SkipsIneligible(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Weight: 2, Status: ServerStatusDOWN},
+		{URL: "b", Weight: 2, Status: ServerStatusHEALTHY},
+	}
+	strategy := NewRoundRobinStrategy(2, 1)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 0; i < 4; i++ {
+		if got := strategy.Pick(servers, r); got.URL != "b" {
+			t.Errorf("Pick() = %s, want b (only eligible server)", got.URL)
+		}
+	}
+}
+
+func TestRoundRobinStrategy_Pick_ReturnsNilWhenNoneEligible(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Weight: 2, Status: ServerStatusDOWN},
+		{URL: "b", Weight: 2, Status: ServerStatusDOWN},
+	}
+	strategy := NewRoundRobinStrategy(2, 1)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	done := make(chan *Server)
+	go func() { done <- strategy.Pick(servers, r) }()
+
+	select {
+	case got := <-done:
+		if got != nil {
+			t.Errorf("Pick() = %v, want nil when every server is DOWN", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pick() did not return when every server is ineligible")
+	}
+}
+
+func TestIPHashStrategy_Pick_IsStable(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Status: ServerStatusHEALTHY},
+		{URL: "b", Status: ServerStatusHEALTHY},
+	}
+	strategy := &IPHashStrategy{}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:54321"
+
+	first := strategy.Pick(servers, r)
+	second := strategy.Pick(servers, r)
+	if first.URL != second.URL {
+		t.Errorf("Pick() = %s then %s, want the same server for the same client IP", first.URL, second.URL)
+	}
+}
+
+func TestStickySession_Pick_PinsToCookiedServer(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Status: ServerStatusHEALTHY},
+		{URL: "b", Status: ServerStatusHEALTHY},
+	}
+	sticky := NewStickySession(&RandomStrategy{}, "sd_session")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sd_session", Value: "b"})
+
+	if got := sticky.Pick(servers, r); got.URL != "b" {
+		t.Errorf("Pick() = %s, want b (pinned by cookie)", got.URL)
+	}
+}
+
+func TestStickySession_Pick_FallsBackWhenPinnedServerDown(t *testing.T) {
+	servers := []*Server{
+		{URL: "a", Status: ServerStatusHEALTHY},
+		{URL: "b", Status: ServerStatusDOWN},
+	}
+	sticky := NewStickySession(&RandomStrategy{}, "sd_session")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sd_session", Value: "b"})
+
+	if got := sticky.Pick(servers, r); got.URL != "a" {
+		t.Errorf("Pick() = %s, want a (fallback after pinned server went DOWN)", got.URL)
+	}
+}
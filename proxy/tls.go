@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"service-discovery/shared"
+)
+
+// buildServerClient builds the http.Client a Server should use for both
+// proxied requests and health checks, based on the TLS material submitted at
+// registration. Servers without any TLS fields get a plain client, which
+// dials http:// and https:// backends alike.
+func buildServerClient(newServer *shared.NewServer) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: newServer.InsecureSkipVerify,
+		ServerName:         newServer.ServerName,
+	}
+
+	if newServer.RootCAsPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(newServer.RootCAsPEM)) {
+			return nil, fmt.Errorf("invalid root_cas_pem: no certificates could be parsed")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if newServer.ClientCertPEM != "" || newServer.ClientKeyPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(newServer.ClientCertPEM), []byte(newServer.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client_cert_pem/client_key_pem: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
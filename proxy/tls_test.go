@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"service-discovery/shared"
+)
+
+func generateTestCertPEM(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return certPEM, keyPEM
+}
+
+func TestBuildServerClient_PlainServer(t *testing.T) {
+	client, err := buildServerClient(&shared.NewServer{URL: "http://localhost:8080"})
+	if err != nil {
+		t.Fatalf("buildServerClient() error = %v", err)
+	}
+	if client == nil {
+		t.Fatal("buildServerClient() returned nil client")
+	}
+}
+
+func TestBuildServerClient_InvalidRootCAsPEM(t *testing.T) {
+	_, err := buildServerClient(&shared.NewServer{URL: "https://localhost:8443", RootCAsPEM: "not a pem"})
+	if err == nil {
+		t.Fatal("buildServerClient() error = nil, want error for invalid root_cas_pem")
+	}
+}
+
+func TestBuildServerClient_InvalidClientKeyPair(t *testing.T) {
+	certPEM, _ := generateTestCertPEM(t)
+	_, err := buildServerClient(&shared.NewServer{
+		URL:           "https://localhost:8443",
+		ClientCertPEM: certPEM,
+		ClientKeyPEM:  "not a key",
+	})
+	if err == nil {
+		t.Fatal("buildServerClient() error = nil, want error for unparseable client key")
+	}
+}
+
+func TestBuildServerClient_ValidClientKeyPair(t *testing.T) {
+	certPEM, keyPEM := generateTestCertPEM(t)
+	client, err := buildServerClient(&shared.NewServer{
+		URL:           "https://localhost:8443",
+		ClientCertPEM: certPEM,
+		ClientKeyPEM:  keyPEM,
+		ServerName:    "example.com",
+	})
+	if err != nil {
+		t.Fatalf("buildServerClient() error = %v", err)
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("client.Transport type = %T, want *http.Transport", client.Transport)
+	}
+	if transport.TLSClientConfig.ServerName != "example.com" {
+		t.Errorf("TLSClientConfig.ServerName = %s, want example.com", transport.TLSClientConfig.ServerName)
+	}
+	if len(transport.TLSClientConfig.Certificates) != 1 {
+		t.Errorf("len(TLSClientConfig.Certificates) = %d, want 1", len(transport.TLSClientConfig.Certificates))
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+)
+
+// redactedHeaders lists request/response headers whose values must never be
+// forwarded to trace observers.
+var redactedHeaders = map[string]struct{}{
+	"Authorization": {},
+	"Cookie":        {},
+	"Set-Cookie":    {},
+}
+
+// TraceKind distinguishes a proxied call from a health-check probe.
+type TraceKind string
+
+const (
+	TraceKindCall   TraceKind = "call"
+	TraceKindHealth TraceKind = "health"
+)
+
+// TraceInfo is a single observable event emitted either by ProxyHandler or
+// the HealthChecker, and streamed to /trace subscribers.
+type TraceInfo struct {
+	Kind        TraceKind           `json:"kind"`
+	Timestamp   time.Time           `json:"timestamp"`
+	NodeURL     string              `json:"node_url"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	ReqHeaders  map[string][]string `json:"req_headers,omitempty"`
+	RespHeaders map[string][]string `json:"resp_headers,omitempty"`
+	StatusCode  int                 `json:"status_code,omitempty"`
+	LatencyMs   int64               `json:"latency_ms"`
+	BytesIn     int64               `json:"bytes_in,omitempty"`
+	BytesOut    int64               `json:"bytes_out,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// redactHeaders copies h, replacing the value of any redactedHeaders entry
+// with "REDACTED".
+func redactHeaders(h http.Header) map[string][]string {
+	redacted := make(map[string][]string, len(h))
+	for key, values := range h {
+		if _, sensitive := redactedHeaders[http.CanonicalHeaderKey(key)]; sensitive {
+			redacted[key] = []string{"REDACTED"}
+			continue
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// Tracer fans out TraceInfo events to any number of /trace subscribers,
+// backed by a bounded ring buffer so a slow subscriber can't back-pressure
+// request handling.
+type Tracer struct {
+	mu          sync.Mutex
+	buffer      []TraceInfo
+	bufferSize  int
+	subscribers map[chan TraceInfo]struct{}
+}
+
+// NewTracer builds a Tracer retaining at most bufferSize past events for
+// subscribers that want recent history.
+func NewTracer(bufferSize int) *Tracer {
+	return &Tracer{
+		bufferSize:  bufferSize,
+		subscribers: map[chan TraceInfo]struct{}{},
+	}
+}
+
+// Emit records info and forwards it to every current subscriber. Forwarding
+// never blocks: a subscriber whose channel is full simply misses the event.
+func (t *Tracer) Emit(info TraceInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buffer = append(t.buffer, info)
+	if len(t.buffer) > t.bufferSize {
+		t.buffer = t.buffer[len(t.buffer)-t.bufferSize:]
+	}
+	for sub := range t.subscribers {
+		select {
+		case sub <- info:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a new observer and returns the channel it will receive
+// future events on, a snapshot of the buffered history emitted before this
+// call (oldest first), and an unsubscribe func the caller must invoke when
+// done.
+func (t *Tracer) Subscribe() (chan TraceInfo, []TraceInfo, func()) {
+	ch := make(chan TraceInfo, 64)
+	t.mu.Lock()
+	history := make([]TraceInfo, len(t.buffer))
+	copy(history, t.buffer)
+	t.subscribers[ch] = struct{}{}
+	t.mu.Unlock()
+
+	unsubscribe := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subscribers[ch]; !ok {
+			return
+		}
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+	return ch, history, unsubscribe
+}
+
+// TraceFilter narrows down which TraceInfo events a /trace subscriber wants
+// to receive, parsed from its query string.
+type TraceFilter struct {
+	Nodes        map[string]struct{}
+	StatusCode   int
+	MinLatencyMs int64
+	Only         TraceKind
+}
+
+// parseTraceFilter reads ?nodes=, ?status_code=, ?min_latency_ms= and
+// ?only=call|health off of r.
+func parseTraceFilter(r *http.Request) TraceFilter {
+	filter := TraceFilter{Only: TraceKind(r.URL.Query().Get("only"))}
+	if nodes := r.URL.Query()["nodes"]; len(nodes) > 0 {
+		filter.Nodes = make(map[string]struct{}, len(nodes))
+		for _, node := range nodes {
+			filter.Nodes[node] = struct{}{}
+		}
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("status_code")); err == nil {
+		filter.StatusCode = v
+	}
+	if v, err := strconv.ParseInt(r.URL.Query().Get("min_latency_ms"), 10, 64); err == nil {
+		filter.MinLatencyMs = v
+	}
+	return filter
+}
+
+// Matches reports whether info satisfies every configured filter criterion.
+func (f TraceFilter) Matches(info TraceInfo) bool {
+	if f.Only != "" && info.Kind != f.Only {
+		return false
+	}
+	if f.Nodes != nil {
+		if _, ok := f.Nodes[info.NodeURL]; !ok {
+			return false
+		}
+	}
+	if f.StatusCode != 0 && info.StatusCode != f.StatusCode {
+		return false
+	}
+	if info.LatencyMs < f.MinLatencyMs {
+		return false
+	}
+	return true
+}
+
+var traceUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Tracing is an operator tool, not a browser-facing feature, so any
+	// origin is allowed to connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// TraceHandler upgrades GET /trace to a WebSocket and streams matching
+// TraceInfo events to the caller as NDJSON, one event per text frame.
+func (lb *LoadBalancer) TraceHandler(ctx echo.Context) error {
+	conn, err := traceUpgrader.Upgrade(ctx.Response().Writer, ctx.Request(), nil)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to upgrade to websocket")
+	}
+	defer conn.Close()
+
+	filter := parseTraceFilter(ctx.Request())
+	events, history, unsubscribe := lb.Tracer.Subscribe()
+	defer unsubscribe()
+
+	for _, event := range history {
+		if !filter.Matches(event) {
+			continue
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+			return nil
+		}
+	}
+	for event := range events {
+		if !filter.Matches(event) {
+			continue
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
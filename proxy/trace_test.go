@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTracer_Emit_FanOutToSubscribers(t *testing.T) {
+	tracer := NewTracer(10)
+	events, _, unsubscribe := tracer.Subscribe()
+	defer unsubscribe()
+
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://a"})
+
+	select {
+	case got := <-events:
+		if got.NodeURL != "http://a" {
+			t.Errorf("NodeURL = %s, want http://a", got.NodeURL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber did not receive emitted event")
+	}
+}
+
+func TestTracer_Emit_DoesNotBlockOnFullSubscriber(t *testing.T) {
+	tracer := NewTracer(10)
+	_, _, unsubscribe := tracer.Subscribe() // never drained
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			tracer.Emit(TraceInfo{Kind: TraceKindCall})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Emit blocked on a slow subscriber")
+	}
+}
+
+func TestTracer_Subscribe_ReplaysBufferedHistory(t *testing.T) {
+	tracer := NewTracer(10)
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://a"})
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://b"})
+
+	_, history, unsubscribe := tracer.Subscribe()
+	defer unsubscribe()
+
+	if len(history) != 2 {
+		t.Fatalf("len(history) = %d, want 2", len(history))
+	}
+	if history[0].NodeURL != "http://a" || history[1].NodeURL != "http://b" {
+		t.Errorf("history = %v, want [http://a, http://b] in emission order", history)
+	}
+}
+
+func TestTracer_Subscribe_HistoryCappedAtBufferSize(t *testing.T) {
+	tracer := NewTracer(2)
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://a"})
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://b"})
+	tracer.Emit(TraceInfo{Kind: TraceKindCall, NodeURL: "http://c"})
+
+	_, history, unsubscribe := tracer.Subscribe()
+	defer unsubscribe()
+
+	if len(history) != 2 {
+		t.Fatalf("len(history) = %d, want 2", len(history))
+	}
+	if history[0].NodeURL != "http://b" || history[1].NodeURL != "http://c" {
+		t.Errorf("history = %v, want [http://b, http://c] (oldest evicted)", history)
+	}
+}
+
+func TestTraceFilter_Matches(t *testing.T) {
+	filter := TraceFilter{Only: TraceKindCall, StatusCode: 500, MinLatencyMs: 100}
+
+	matching := TraceInfo{Kind: TraceKindCall, StatusCode: 500, LatencyMs: 150}
+	if !filter.Matches(matching) {
+		t.Error("Matches() = false, want true for an event satisfying every criterion")
+	}
+
+	wrongKind := matching
+	wrongKind.Kind = TraceKindHealth
+	if filter.Matches(wrongKind) {
+		t.Error("Matches() = true, want false for a health event when Only=call")
+	}
+
+	tooFast := matching
+	tooFast.LatencyMs = 10
+	if filter.Matches(tooFast) {
+		t.Error("Matches() = true, want false for latency below MinLatencyMs")
+	}
+}
+
+func TestParseTraceFilter(t *testing.T) {
+	r := &http.Request{URL: &url.URL{RawQuery: "only=health&status_code=503&min_latency_ms=50"}}
+	filter := parseTraceFilter(r)
+	if filter.Only != TraceKindHealth {
+		t.Errorf("Only = %s, want health", filter.Only)
+	}
+	if filter.StatusCode != 503 {
+		t.Errorf("StatusCode = %d, want 503", filter.StatusCode)
+	}
+	if filter.MinLatencyMs != 50 {
+		t.Errorf("MinLatencyMs = %d, want 50", filter.MinLatencyMs)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "secret-token")
+	h.Set("X-Request-Id", "abc123")
+
+	redacted := redactHeaders(h)
+	if redacted["Authorization"][0] != "REDACTED" {
+		t.Errorf("Authorization = %v, want REDACTED", redacted["Authorization"])
+	}
+	if redacted["X-Request-Id"][0] != "abc123" {
+		t.Errorf("X-Request-Id = %v, want untouched", redacted["X-Request-Id"])
+	}
+}
@@ -0,0 +1,36 @@
+package shared
+
+// NewServer is the payload an application instance POSTs to the discovery
+// service's /register endpoint to announce itself.
+type NewServer struct {
+	URL string `json:"url"`
+	// Weight, when set, replaces the load balancer's default weight as this
+	// server's starting weight. It does not persist: the load balancer resets
+	// the weight to its normal weight after a fast request, and to 1 after
+	// repeated slow ones. Only consulted by weight-aware balancing strategies.
+	Weight int `json:"weight,omitempty"`
+
+	// Strategy, when set, switches the load balancer's active balancing
+	// strategy (same names as the -strategy flag: round_robin,
+	// weighted_random, random, ip_hash, header_hash) so an operator can
+	// change routing behavior per deployment without restarting the process.
+	Strategy string `json:"strategy,omitempty"`
+
+	// Health check overrides. A zero value means "use the load balancer's
+	// HealthChecker defaults" for that field.
+	HealthCheckPath                string `json:"health_check_path,omitempty"`
+	HealthCheckMethod              string `json:"health_check_method,omitempty"`
+	HealthCheckIntervalMs          int64  `json:"health_check_interval_ms,omitempty"`
+	HealthCheckTimeoutMs           int64  `json:"health_check_timeout_ms,omitempty"`
+	HealthCheckAcceptedStatusCodes []int  `json:"health_check_accepted_status_codes,omitempty"`
+	UnhealthyThreshold             int    `json:"unhealthy_threshold,omitempty"`
+	HealthyThreshold               int    `json:"healthy_threshold,omitempty"`
+
+	// TLS options for https:// backends. All optional; a server with a
+	// plain http:// URL can leave these unset.
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	RootCAsPEM         string `json:"root_cas_pem,omitempty"`
+	ClientCertPEM      string `json:"client_cert_pem,omitempty"`
+	ClientKeyPEM       string `json:"client_key_pem,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+}